@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LocalVolumeNodeProfileSpec lets a subset of nodes override the fsType
+// and mount options a LocalVolume/LocalVolumeSet would otherwise apply
+// uniformly across the cluster. The device class cannot be overridden per
+// profile.
+type LocalVolumeNodeProfileSpec struct {
+	// NodeSelector restricts this profile to the nodes it matches. Nodes
+	// matched by more than one LocalVolumeNodeProfile are assigned to
+	// whichever profile sorts first by name.
+	NodeSelector *corev1.NodeSelector `json:"nodeSelector"`
+
+	// FSType overrides the filesystem type used when formatting devices
+	// on a matched node. When empty, the matched nodes' provisioner config
+	// carries no fsType; the owning LocalVolume/LocalVolumeSet's fsType is
+	// not copied in.
+	// +optional
+	FSType string `json:"fsType,omitempty"`
+
+	// MountOptions overrides the mount options used on a matched node.
+	// When empty, the matched nodes' provisioner config carries no mount
+	// options; nothing is taken from the owning LocalVolume/LocalVolumeSet.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
+// LocalVolumeNodeProfileStatus holds the profile's conditions. It does not
+// list the nodes currently resolved to this profile.
+type LocalVolumeNodeProfileStatus struct {
+	// Conditions represents the latest available observations of the
+	// profile's state.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LocalVolumeNodeProfile lets operators specialize the fsType and mount
+// options local-storage-operator applies on a subset of nodes, for
+// clusters with heterogeneous hardware.
+type LocalVolumeNodeProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LocalVolumeNodeProfileSpec   `json:"spec"`
+	Status LocalVolumeNodeProfileStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LocalVolumeNodeProfileList contains a list of LocalVolumeNodeProfile
+type LocalVolumeNodeProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LocalVolumeNodeProfile `json:"items"`
+}
@@ -0,0 +1,37 @@
+package nodedaemon
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fieldOwner identifies this controller's server-side-apply field set on
+// the DaemonSets and ConfigMap it reconciles, so edits made to other
+// fields by other actors (admission webhooks, other controllers) survive
+// across reconciles instead of being clobbered by a blind GET-then-mutate.
+const fieldOwner = client.FieldOwner("local-storage-operator/nodedaemon")
+
+// applyDaemonSet server-side applies ds, taking ownership of the fields it
+// sets and forcing ownership of any field currently claimed by another
+// manager. The returned DaemonSet reflects the object as stored by the
+// apiserver after the patch.
+func (r *DaemonReconciler) applyDaemonSet(ds *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	applied := ds.DeepCopy()
+	if err := r.client.Patch(context.TODO(), applied, client.Apply, fieldOwner, client.ForceOwnership); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// applyConfigMap server-side applies cm the same way applyDaemonSet does
+// for DaemonSets.
+func (r *DaemonReconciler) applyConfigMap(cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	applied := cm.DeepCopy()
+	if err := r.client.Patch(context.TODO(), applied, client.Apply, fieldOwner, client.ForceOwnership); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
@@ -0,0 +1,106 @@
+package nodedaemon
+
+import (
+	"context"
+	"testing"
+
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("adding appsv1 to scheme: %v", err)
+	}
+	if err := localv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("adding local v1alpha1 to scheme: %v", err)
+	}
+	return scheme
+}
+
+// TestOwnerRefFor verifies the GroupVersionKind is resolved from the
+// scheme, not read back off the object's TypeMeta - a typed client never
+// populates TypeMeta on objects it returns, so reading it back always
+// produced an OwnerReference with an empty apiVersion/kind.
+func TestOwnerRefFor(t *testing.T) {
+	r := &DaemonReconciler{scheme: newTestScheme(t)}
+
+	lvSet := &localv1alpha1.LocalVolumeSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "local-storage", UID: "abc-123"},
+	}
+
+	ref, err := r.ownerRefFor(lvSet, lvSet.ObjectMeta)
+	if err != nil {
+		t.Fatalf("ownerRefFor returned error: %v", err)
+	}
+	if ref.APIVersion != "local.storage.openshift.io/v1alpha1" {
+		t.Errorf("APIVersion = %q, want %q", ref.APIVersion, "local.storage.openshift.io/v1alpha1")
+	}
+	if ref.Kind != "LocalVolumeSet" {
+		t.Errorf("Kind = %q, want %q", ref.Kind, "LocalVolumeSet")
+	}
+	if ref.Name != "example" {
+		t.Errorf("Name = %q, want %q", ref.Name, "example")
+	}
+	if ref.UID != lvSet.UID {
+		t.Errorf("UID = %q, want %q", ref.UID, lvSet.UID)
+	}
+}
+
+// patchRecordingClient wraps a client.Client and records the last Patch
+// call it saw instead of applying it, so tests can assert on what
+// applyDaemonSet asked the apiserver to do without depending on a fake
+// client actually implementing field-manager conflict semantics - the
+// fake client in this tree's controller-runtime vintage does not merge
+// server-side-apply patches, it just overwrites the stored object, which
+// would make an end-to-end "foreign field survives" test pass (or fail)
+// for the wrong reason.
+type patchRecordingClient struct {
+	client.Client
+	patch   client.Patch
+	options []client.PatchOption
+}
+
+func (c *patchRecordingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patch = patch
+	c.options = opts
+	return nil
+}
+
+// TestApplyDaemonSetUsesForceOwnedServerSideApply verifies applyDaemonSet
+// issues the patch our field-ownership model depends on: a
+// server-side-apply patch, force-owned under our field manager. That
+// combination is what lets us reapply our own fields every reconcile
+// without clobbering fields another manager owns.
+func TestApplyDaemonSetUsesForceOwnedServerSideApply(t *testing.T) {
+	rec := &patchRecordingClient{}
+	r := &DaemonReconciler{client: rec, scheme: newTestScheme(t)}
+
+	request := reconcile.Request{NamespacedName: client.ObjectKey{Namespace: "local-storage", Name: "example"}}
+	ds := buildDiskMakerDaemonSet(request, nil, nil, nil, "hash-1", DiskMakerName, provisionerConfigMapName)
+
+	if _, err := r.applyDaemonSet(ds); err != nil {
+		t.Fatalf("applyDaemonSet: %v", err)
+	}
+
+	if rec.patch != client.Apply {
+		t.Errorf("patch type = %v, want client.Apply", rec.patch)
+	}
+
+	opts := &client.PatchOptions{}
+	for _, opt := range rec.options {
+		opt.ApplyToPatch(opts)
+	}
+	if opts.FieldManager != string(fieldOwner) {
+		t.Errorf("FieldManager = %q, want %q", opts.FieldManager, string(fieldOwner))
+	}
+	if opts.Force == nil || !*opts.Force {
+		t.Errorf("Force = %v, want true", opts.Force)
+	}
+}
@@ -0,0 +1,404 @@
+package nodedaemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	localv1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1"
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// cleanupFinalizer blocks deletion of a LocalVolume/LocalVolumeSet
+	// until every per-node cleanup Job it owns has reported success.
+	cleanupFinalizer = "local.storage.openshift.io/nodedaemon-cleanup"
+
+	// wipeDataAnnotationKey opts a LocalVolume/LocalVolumeSet into wiping
+	// the underlying device data on deletion. Without it, cleanup only
+	// removes the symlinks/mountpoints the static provisioner created and
+	// leaves the data on disk untouched.
+	wipeDataAnnotationKey = "local.storage.openshift.io/wipe-data"
+
+	cleanupImageEnvName = "CLEANUP_IMAGE"
+	cleanupJobLabelKey  = "local.storage.openshift.io/cleanup-owner"
+	pvOwnerLabelKey     = "local.storage.openshift.io/owner-name"
+
+	conditionCleanupProgressing = "CleanupProgressing"
+
+	// cleanupRequeueAfter is how soon Reconcile asks to be called back
+	// while a cleanup is still in progress. Wiping a real disk can take
+	// far longer than this, so the wait for Jobs to finish is spread
+	// across reconciles via RequeueAfter rather than held open inside a
+	// single call.
+	cleanupRequeueAfter = 15 * time.Second
+)
+
+// clusterObject is the subset of a CR this file needs in order to manage
+// finalizers generically across LocalVolume and LocalVolumeSet.
+type clusterObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// cleanupOwner is the subset of a LocalVolume/LocalVolumeSet the cleanup
+// subsystem needs, shared across both CR types so the same code path
+// handles either.
+type cleanupOwner struct {
+	obj          clusterObject
+	objectMeta   metav1.ObjectMeta
+	tolerations  []corev1.Toleration
+	nodeSelector *corev1.NodeSelector
+}
+
+func (o cleanupOwner) wipeData() bool {
+	_, ok := o.objectMeta.Annotations[wipeDataAnnotationKey]
+	return ok
+}
+
+func containsFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	out := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		if f != finalizer {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// ensureCleanupFinalizer adds cleanupFinalizer to obj if it isn't already
+// present, so deletion is blocked until cleanup has run.
+func (r *DaemonReconciler) ensureCleanupFinalizer(obj clusterObject) error {
+	if containsFinalizer(obj.GetFinalizers(), cleanupFinalizer) {
+		return nil
+	}
+	obj.SetFinalizers(append(obj.GetFinalizers(), cleanupFinalizer))
+	return r.client.Update(context.TODO(), obj)
+}
+
+// handleLocalVolumeSetDeletion drives cleanup for a LocalVolumeSet that
+// has a DeletionTimestamp set, dropping the finalizer once every per-node
+// Job has succeeded and the released PVs it owned are gone. It reports
+// done=false, with no error, while cleanup is still running - the caller
+// requeues rather than treating that as a failure.
+func (r *DaemonReconciler) handleLocalVolumeSetDeletion(lvSet *localv1alpha1.LocalVolumeSet) (bool, error) {
+	if !containsFinalizer(lvSet.Finalizers, cleanupFinalizer) {
+		return true, nil
+	}
+	owner := cleanupOwner{
+		obj:          lvSet,
+		objectMeta:   lvSet.ObjectMeta,
+		tolerations:  lvSet.Spec.Tolerations,
+		nodeSelector: lvSet.Spec.NodeSelector,
+	}
+	done, err := r.reconcileCleanup(owner, func(succeeded, total int) error {
+		return r.patchLocalVolumeSetCleanupProgress(lvSet, succeeded, total)
+	})
+	if err != nil || !done {
+		return false, err
+	}
+	if err := r.releasePVs(owner.objectMeta); err != nil {
+		return false, err
+	}
+	lvSet.Finalizers = removeFinalizer(lvSet.Finalizers, cleanupFinalizer)
+	return true, r.client.Update(context.TODO(), lvSet)
+}
+
+// handleLocalVolumeDeletion is the LocalVolume equivalent of
+// handleLocalVolumeSetDeletion.
+func (r *DaemonReconciler) handleLocalVolumeDeletion(lv *localv1.LocalVolume) (bool, error) {
+	if !containsFinalizer(lv.Finalizers, cleanupFinalizer) {
+		return true, nil
+	}
+	owner := cleanupOwner{
+		obj:          lv,
+		objectMeta:   lv.ObjectMeta,
+		tolerations:  lv.Spec.Tolerations,
+		nodeSelector: lv.Spec.NodeSelector,
+	}
+	done, err := r.reconcileCleanup(owner, func(succeeded, total int) error {
+		return r.patchLocalVolumeCleanupProgress(lv, succeeded, total)
+	})
+	if err != nil || !done {
+		return false, err
+	}
+	if err := r.releasePVs(owner.objectMeta); err != nil {
+		return false, err
+	}
+	lv.Finalizers = removeFinalizer(lv.Finalizers, cleanupFinalizer)
+	return true, r.client.Update(context.TODO(), lv)
+}
+
+// reconcileCleanup ensures one cleanup Job per matching node exists for
+// owner, then checks each Job's current status once, calling
+// reportProgress with the result so the caller can surface how many nodes
+// are done onto the owning CR's status. It reports done=true only once
+// every Job has succeeded; otherwise the caller is expected to requeue
+// and call back in, rather than this function blocking - wiping a real
+// disk can easily run for much longer than a single reconcile should.
+func (r *DaemonReconciler) reconcileCleanup(owner cleanupOwner, reportProgress func(succeeded, total int) error) (bool, error) {
+	nodes, err := r.matchingNodes(owner.nodeSelector)
+	if err != nil {
+		return false, err
+	}
+
+	for _, node := range nodes {
+		if _, err := r.ensureCleanupJob(owner, node); err != nil {
+			return false, err
+		}
+	}
+
+	succeeded := 0
+	for _, node := range nodes {
+		job := &batchv1.Job{}
+		name := cleanupJobName(owner.objectMeta.Name, node.Name)
+		if err := r.client.Get(context.TODO(), client.ObjectKey{Namespace: owner.objectMeta.Namespace, Name: name}, job); err != nil {
+			return false, err
+		}
+		if job.Status.Succeeded >= 1 {
+			succeeded++
+		}
+	}
+	if err := reportProgress(succeeded, len(nodes)); err != nil {
+		return false, err
+	}
+	return succeeded == len(nodes), nil
+}
+
+// cleanupProgressCondition summarizes how many of a CR's per-node cleanup
+// Jobs have succeeded so far.
+func cleanupProgressCondition(succeeded, total int) metav1.Condition {
+	status := metav1.ConditionTrue
+	reason := "CleanupInProgress"
+	if total > 0 && succeeded >= total {
+		status = metav1.ConditionFalse
+		reason = "CleanupComplete"
+	}
+	return metav1.Condition{
+		Type:               conditionCleanupProgressing,
+		Status:             status,
+		Reason:             reason,
+		Message:            fmt.Sprintf("%d/%d node cleanup jobs succeeded", succeeded, total),
+		LastTransitionTime: metav1.Now(),
+	}
+}
+
+// patchLocalVolumeSetCleanupProgress status-patches the current cleanup
+// progress onto lvSet.
+func (r *DaemonReconciler) patchLocalVolumeSetCleanupProgress(lvSet *localv1alpha1.LocalVolumeSet, succeeded, total int) error {
+	patch := client.MergeFrom(lvSet.DeepCopy())
+	lvSet.Status.Conditions = mergeConditions(lvSet.Status.Conditions, []metav1.Condition{cleanupProgressCondition(succeeded, total)})
+	return r.client.Status().Patch(context.TODO(), lvSet, patch)
+}
+
+// patchLocalVolumeCleanupProgress is the LocalVolume equivalent of
+// patchLocalVolumeSetCleanupProgress.
+func (r *DaemonReconciler) patchLocalVolumeCleanupProgress(lv *localv1.LocalVolume, succeeded, total int) error {
+	patch := client.MergeFrom(lv.DeepCopy())
+	lv.Status.Conditions = mergeConditions(lv.Status.Conditions, []metav1.Condition{cleanupProgressCondition(succeeded, total)})
+	return r.client.Status().Patch(context.TODO(), lv, patch)
+}
+
+func (r *DaemonReconciler) ensureCleanupJob(owner cleanupOwner, node corev1.Node) (*batchv1.Job, error) {
+	name := cleanupJobName(owner.objectMeta.Name, node.Name)
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), client.ObjectKey{Namespace: owner.objectMeta.Namespace, Name: name}, job)
+	if err == nil {
+		return job, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	job, err = r.buildCleanupJob(owner, node, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.client.Create(context.TODO(), job); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return job, nil
+}
+
+func cleanupJobName(ownerName, nodeName string) string {
+	return fmt.Sprintf("%s-cleanup-%s", ownerName, nodeName)
+}
+
+// buildCleanupJob builds the privileged per-node Job that wipes the
+// symlinks/mountpoints the static provisioner created for owner, and also
+// wipes the backing device data when owner.wipeData() opts in. It mounts
+// both hostLocalStorageDir and hostDevDir, since wipe mode needs a path to
+// the actual block devices, not just the symlinks pointing at them.
+func (r *DaemonReconciler) buildCleanupJob(owner cleanupOwner, node corev1.Node, name string) (*batchv1.Job, error) {
+	ownerRef, err := r.ownerRefFor(owner.obj, owner.objectMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	backoffLimit := int32(6)
+	mode := "links"
+	if owner.wipeData() {
+		mode = "wipe"
+	}
+	labels := map[string]string{cleanupJobLabelKey: owner.objectMeta.Name}
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       owner.objectMeta.Namespace,
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{ownerRef},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					NodeName:      node.Name,
+					Tolerations:   owner.tolerations,
+					Containers: []corev1.Container{
+						{
+							Name:            "cleanup",
+							Image:           os.Getenv(cleanupImageEnvName),
+							Args:            []string{"cleanup", "--mode=" + mode, "--owner=" + owner.objectMeta.Name},
+							SecurityContext: &corev1.SecurityContext{Privileged: boolPtr(true)},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "local-disks", MountPath: hostLocalStorageDir},
+								{Name: "device-dir", MountPath: hostDevDir},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "local-disks", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: hostLocalStorageDir}}},
+						{Name: "device-dir", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: hostDevDir}}},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// releasePVs deletes the Released PVs that belong to owner, once the
+// per-node cleanup Jobs that wiped their symlinks/data have succeeded.
+func (r *DaemonReconciler) releasePVs(ownerMeta metav1.ObjectMeta) error {
+	pvList := &corev1.PersistentVolumeList{}
+	if err := r.client.List(context.TODO(), pvList, client.MatchingLabels{pvOwnerLabelKey: ownerMeta.Name}); err != nil {
+		return err
+	}
+	for i := range pvList.Items {
+		pv := &pvList.Items[i]
+		if pv.Status.Phase != corev1.VolumeReleased {
+			continue
+		}
+		if err := r.client.Delete(context.TODO(), pv); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchingNodes returns every Node matching nodeSelector, or every Node in
+// the cluster when nodeSelector is nil.
+func (r *DaemonReconciler) matchingNodes(nodeSelector *corev1.NodeSelector) ([]corev1.Node, error) {
+	nodeList := &corev1.NodeList{}
+	if err := r.client.List(context.TODO(), nodeList); err != nil {
+		return nil, err
+	}
+	if nodeSelector == nil {
+		return nodeList.Items, nil
+	}
+	matched := make([]corev1.Node, 0, len(nodeList.Items))
+	for _, node := range nodeList.Items {
+		if nodeMatchesSelector(node, *nodeSelector) {
+			matched = append(matched, node)
+		}
+	}
+	return matched, nil
+}
+
+func nodeMatchesSelector(node corev1.Node, selector corev1.NodeSelector) bool {
+	for _, term := range selector.NodeSelectorTerms {
+		if nodeMatchesTerm(node, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeMatchesTerm(node corev1.Node, term corev1.NodeSelectorTerm) bool {
+	for _, req := range term.MatchExpressions {
+		if !nodeMatchesExpression(node.Labels, req) {
+			return false
+		}
+	}
+	for _, req := range term.MatchFields {
+		if !nodeMatchesField(node, req) {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeMatchesExpression(labels map[string]string, req corev1.NodeSelectorRequirement) bool {
+	value, found := labels[req.Key]
+	switch req.Operator {
+	case corev1.NodeSelectorOpExists:
+		return found
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !found
+	case corev1.NodeSelectorOpIn:
+		return found && containsString(req.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !found || !containsString(req.Values, value)
+	default:
+		return false
+	}
+}
+
+// nodeMatchesField evaluates a NodeSelectorTerm.MatchFields requirement.
+// metadata.name is the only field the apiserver's own node affinity
+// evaluator supports; any other field key is treated as not matching
+// rather than silently matching every node.
+func nodeMatchesField(node corev1.Node, req corev1.NodeSelectorRequirement) bool {
+	if req.Key != "metadata.name" {
+		return false
+	}
+	switch req.Operator {
+	case corev1.NodeSelectorOpExists:
+		return node.Name != ""
+	case corev1.NodeSelectorOpDoesNotExist:
+		return node.Name == ""
+	case corev1.NodeSelectorOpIn:
+		return containsString(req.Values, node.Name)
+	case corev1.NodeSelectorOpNotIn:
+		return !containsString(req.Values, node.Name)
+	default:
+		return false
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package nodedaemon
+
+import (
+	localv1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1"
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+)
+
+// provisionerConfig mirrors the local-static-provisioner config.yaml layout:
+// one entry per storage class name found across every
+// LocalVolume/LocalVolumeSet in the namespace.
+type provisionerConfig struct {
+	StorageClassConfig map[string]storageClassConfig `json:"storageClassMap"`
+}
+
+type storageClassConfig struct {
+	HostDir      string   `json:"hostDir"`
+	MountDir     string   `json:"mountDir"`
+	FSType       string   `json:"fsType,omitempty"`
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
+// buildProvisionerConfigMap renders the desired state of a profile's
+// provisioner ConfigMap from every LocalVolumeSet/LocalVolume found in
+// the namespace, with override layered on top when the ConfigMap belongs
+// to a LocalVolumeNodeProfile rather than the base (unprofiled) group.
+// Unlike the old reconcileProvisionerConfigMap, it never reads the
+// existing object back - it only builds the object to be server-side
+// applied by the caller.
+func buildProvisionerConfigMap(request reconcile.Request, lvSets []localv1alpha1.LocalVolumeSet, lvs []localv1.LocalVolume, ownerRefs []metav1.OwnerReference, name string, override *localv1alpha1.LocalVolumeNodeProfileSpec) (*corev1.ConfigMap, error) {
+	cfg := provisionerConfig{StorageClassConfig: map[string]storageClassConfig{}}
+
+	for _, lvSet := range lvSets {
+		cfg.StorageClassConfig[lvSet.Spec.StorageClassName] = storageClassEntry(override)
+	}
+	for _, lv := range lvs {
+		for _, scDevice := range lv.Spec.StorageClassDevices {
+			cfg.StorageClassConfig[scDevice.StorageClassName] = storageClassEntry(override)
+		}
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       request.Namespace,
+			OwnerReferences: ownerRefs,
+		},
+		Data: map[string]string{
+			"config.yaml": string(data),
+		},
+	}, nil
+}
+
+func storageClassEntry(override *localv1alpha1.LocalVolumeNodeProfileSpec) storageClassConfig {
+	entry := storageClassConfig{
+		HostDir:  hostLocalStorageDir,
+		MountDir: hostLocalStorageDir,
+	}
+	if override != nil {
+		entry.FSType = override.FSType
+		entry.MountOptions = override.MountOptions
+	}
+	return entry
+}
@@ -0,0 +1,99 @@
+package nodedaemon
+
+import (
+	"strings"
+
+	localv1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1"
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const controllerName = "nodedaemon-controller"
+
+// aggregatedRequestName is the request name used when a managed DaemonSet
+// event is folded into a single per-namespace reconcile. Reconcile only
+// ever looks at request.Namespace, so the name itself is a placeholder.
+const aggregatedRequestName = "nodedaemon-aggregate"
+
+// Add creates a new DaemonReconciler and adds it to mgr.
+func Add(mgr manager.Manager) error {
+	// LocalVolumeNodeProfile is new enough that it may not be wired into
+	// the scheme by pkg/apis yet; registering it here too is a no-op once
+	// it is, and required if it isn't.
+	if err := localv1alpha1.AddToScheme(mgr.GetScheme()); err != nil {
+		return err
+	}
+
+	r := &DaemonReconciler{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor(controllerName),
+	}
+
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	if err := c.Watch(&source.Kind{Type: &localv1.LocalVolume{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &localv1alpha1.LocalVolumeSet{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+
+	// A LocalVolumeNodeProfile change can move nodes between profiles, so
+	// it needs the same aggregated per-namespace reconcile a DaemonSet
+	// event gets.
+	if err := c.Watch(&source.Kind{Type: &localv1alpha1.LocalVolumeNodeProfile{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(aggregatedDaemonSetRequest),
+	}); err != nil {
+		return err
+	}
+
+	// Watch the DaemonSets we manage so a flapping diskmaker/provisioner
+	// pod on a node requeues a reconcile instead of waiting for the next
+	// LocalVolume/LocalVolumeSet event. Every event folds into a single
+	// aggregated reconcile per namespace rather than one per owner.
+	if err := c.Watch(&source.Kind{Type: &appsv1.DaemonSet{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(aggregatedDaemonSetRequest),
+	}, managedDaemonSetPredicate()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func aggregatedDaemonSetRequest(a handler.MapObject) []reconcile.Request {
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: a.Meta.GetNamespace(), Name: aggregatedRequestName}},
+	}
+}
+
+// managedDaemonSetPredicate restricts the DaemonSet watch to the objects
+// this controller owns, identified by the same "app" label used to build
+// them. A profile's DaemonSets suffix the base name (e.g.
+// "diskmaker-manager-fast-ssd"), so this matches by prefix.
+func managedDaemonSetPredicate() predicate.Predicate {
+	isManaged := func(meta metav1.Object) bool {
+		label := meta.GetLabels()[appLabelKey]
+		return strings.HasPrefix(label, DiskMakerName) || strings.HasPrefix(label, ProvisionerName)
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return isManaged(e.Meta) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return isManaged(e.MetaNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return isManaged(e.Meta) },
+		GenericFunc: func(e event.GenericEvent) bool { return isManaged(e.Meta) },
+	}
+}
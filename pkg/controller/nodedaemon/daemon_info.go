@@ -0,0 +1,88 @@
+package nodedaemon
+
+import (
+	"context"
+
+	localv1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1"
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// aggregateDeamonInfo lists every LocalVolumeSet and LocalVolume in the
+// request namespace and folds their tolerations, owner references and node
+// selectors into the union the diskmaker/provisioner DaemonSets are built
+// from. Several CRs can target the same nodes, so the result always
+// reflects every CR found rather than a single owner.
+func (r *DaemonReconciler) aggregateDeamonInfo(request reconcile.Request) (localv1alpha1.LocalVolumeSetList, localv1.LocalVolumeList, []corev1.Toleration, []metav1.OwnerReference, *corev1.NodeSelector, error) {
+	lvSets := localv1alpha1.LocalVolumeSetList{}
+	lvs := localv1.LocalVolumeList{}
+
+	if err := r.client.List(context.TODO(), &lvSets, client.InNamespace(request.Namespace)); err != nil {
+		return lvSets, lvs, nil, nil, nil, err
+	}
+	if err := r.client.List(context.TODO(), &lvs, client.InNamespace(request.Namespace)); err != nil {
+		return lvSets, lvs, nil, nil, nil, err
+	}
+
+	tolerations := []corev1.Toleration{}
+	ownerRefs := []metav1.OwnerReference{}
+	terms := []corev1.NodeSelectorTerm{}
+
+	for i := range lvSets.Items {
+		lvSet := &lvSets.Items[i]
+		tolerations = append(tolerations, lvSet.Spec.Tolerations...)
+		ownerRef, err := r.ownerRefFor(lvSet, lvSet.ObjectMeta)
+		if err != nil {
+			return lvSets, lvs, nil, nil, nil, err
+		}
+		ownerRefs = append(ownerRefs, ownerRef)
+		if lvSet.Spec.NodeSelector != nil {
+			terms = append(terms, lvSet.Spec.NodeSelector.NodeSelectorTerms...)
+		}
+	}
+	for i := range lvs.Items {
+		lv := &lvs.Items[i]
+		tolerations = append(tolerations, lv.Spec.Tolerations...)
+		ownerRef, err := r.ownerRefFor(lv, lv.ObjectMeta)
+		if err != nil {
+			return lvSets, lvs, nil, nil, nil, err
+		}
+		ownerRefs = append(ownerRefs, ownerRef)
+		if lv.Spec.NodeSelector != nil {
+			terms = append(terms, lv.Spec.NodeSelector.NodeSelectorTerms...)
+		}
+	}
+
+	var nodeSelector *corev1.NodeSelector
+	if len(terms) > 0 {
+		nodeSelector = &corev1.NodeSelector{NodeSelectorTerms: terms}
+	}
+
+	return lvSets, lvs, tolerations, ownerRefs, nodeSelector, nil
+}
+
+// ownerRefFor builds an OwnerReference to obj. Typed Get/List results never
+// populate TypeMeta on the individual items, so the apiVersion/kind can't be
+// read back off the object itself - they're resolved from the manager's
+// scheme instead.
+func (r *DaemonReconciler) ownerRefFor(obj runtime.Object, objectMeta metav1.ObjectMeta) (metav1.OwnerReference, error) {
+	gvks, _, err := r.scheme.ObjectKinds(obj)
+	if err != nil {
+		return metav1.OwnerReference{}, err
+	}
+	gvk := gvks[0]
+	blockOwnerDeletion := true
+	isController := false
+	return metav1.OwnerReference{
+		APIVersion:         gvk.GroupVersion().String(),
+		Kind:               gvk.Kind,
+		Name:               objectMeta.Name,
+		UID:                objectMeta.UID,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+		Controller:         &isController,
+	}, nil
+}
@@ -0,0 +1,158 @@
+package nodedaemon
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	diskMakerImageEnvName      = "DISKMAKER_MANAGER_IMAGE"
+	provisionerImageEnvName    = "PROVISIONER_IMAGE"
+	provisionerConfigMapName   = "local-provisioner-config"
+	serviceAccountName         = "local-storage-admin"
+	hostDevDir                 = "/dev"
+	hostLocalStorageDir        = "/mnt/local-storage"
+	provisionerConfigMountPath = "/etc/provisioner/config"
+)
+
+// dataHash returns a stable hash of a ConfigMap's Data, used to annotate pod
+// templates so a rollout happens whenever the rendered config changes.
+func dataHash(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, data[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func daemonSetObjectMeta(name, namespace string, ownerRefs []metav1.OwnerReference) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:            name,
+		Namespace:       namespace,
+		Labels:          map[string]string{appLabelKey: name},
+		OwnerReferences: ownerRefs,
+	}
+}
+
+func affinityFor(nodeSelector *corev1.NodeSelector) *corev1.Affinity {
+	if nodeSelector == nil {
+		return nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: nodeSelector,
+		},
+	}
+}
+
+func provisionerConfigVolume(configMapName string) corev1.Volume {
+	return corev1.Volume{
+		Name: "provisioner-config",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	}
+}
+
+// buildDiskMakerDaemonSet returns the fully-populated desired state of a
+// profile's diskmaker-manager DaemonSet, named dsName and pointed at
+// configMapName. It replaces the old CreateOrUpdateDaemonset plus
+// mutate-function pattern: the result is server-side applied by the
+// caller rather than GET-then-mutated, so only the fields set here are
+// owned by this controller.
+func buildDiskMakerDaemonSet(request reconcile.Request, tolerations []corev1.Toleration, ownerRefs []metav1.OwnerReference, nodeSelector *corev1.NodeSelector, configMapDataHash, dsName, configMapName string) *appsv1.DaemonSet {
+	labels := map[string]string{appLabelKey: dsName}
+	return &appsv1.DaemonSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+		ObjectMeta: daemonSetObjectMeta(dsName, request.Namespace, ownerRefs),
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{dataHashAnnotationKey: configMapDataHash},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: serviceAccountName,
+					Tolerations:        tolerations,
+					Affinity:           affinityFor(nodeSelector),
+					Containers: []corev1.Container{
+						{
+							Name:  DiskMakerName,
+							Image: os.Getenv(diskMakerImageEnvName),
+							Args:  []string{"diskmaker-manager"},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "device-dir", MountPath: hostDevDir},
+								{Name: "local-disks", MountPath: hostLocalStorageDir},
+								{Name: "provisioner-config", MountPath: provisionerConfigMountPath},
+							},
+							SecurityContext: &corev1.SecurityContext{Privileged: boolPtr(true)},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "device-dir", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: hostDevDir}}},
+						{Name: "local-disks", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: hostLocalStorageDir}}},
+						provisionerConfigVolume(configMapName),
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildProvisionerDaemonSet returns the fully-populated desired state of
+// a profile's local-provisioner DaemonSet, built and applied the same way
+// as buildDiskMakerDaemonSet.
+func buildProvisionerDaemonSet(request reconcile.Request, tolerations []corev1.Toleration, ownerRefs []metav1.OwnerReference, nodeSelector *corev1.NodeSelector, configMapDataHash, dsName, configMapName string) *appsv1.DaemonSet {
+	labels := map[string]string{appLabelKey: dsName}
+	return &appsv1.DaemonSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+		ObjectMeta: daemonSetObjectMeta(dsName, request.Namespace, ownerRefs),
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{dataHashAnnotationKey: configMapDataHash},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: serviceAccountName,
+					Tolerations:        tolerations,
+					Affinity:           affinityFor(nodeSelector),
+					Containers: []corev1.Container{
+						{
+							Name:  ProvisionerName,
+							Image: os.Getenv(provisionerImageEnvName),
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "local-disks", MountPath: hostLocalStorageDir},
+								{Name: "provisioner-config", MountPath: provisionerConfigMountPath},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "local-disks", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: hostLocalStorageDir}}},
+						provisionerConfigVolume(configMapName),
+					},
+				},
+			},
+		},
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
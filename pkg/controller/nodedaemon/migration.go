@@ -0,0 +1,291 @@
+package nodedaemon
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// legacyResourcesConfigMapName, when present, overrides
+	// defaultLegacyResources with a declarative migration list, letting
+	// future renames ship without a Go code change.
+	legacyResourcesConfigMapName = "local-storage-legacy-resources"
+
+	// migrationsConfigMapName records which migrations have already run,
+	// keyed by LegacyResource.ID, so a restart doesn't redo a
+	// list-delete-wait dance that already completed.
+	migrationsConfigMapName = "local-storage-migrations"
+)
+
+// LegacyResource describes one set of objects left behind by an older
+// release that the operator should delete on sight.
+type LegacyResource struct {
+	// ID uniquely identifies this migration. It is the key both in the
+	// legacy resources ConfigMap and in the completed-migrations
+	// ConfigMap, so it must stay stable once shipped.
+	ID string `json:"-"`
+
+	// Kind is the object kind this migration targets. Only "DaemonSet"
+	// is implemented today.
+	Kind string `json:"kind"`
+
+	// LabelSelector, if set, is a standard Kubernetes label selector
+	// string that the object's labels must satisfy.
+	LabelSelector string `json:"labelSelector,omitempty"`
+
+	// NameGlob, if set, is a shell-style glob (as accepted by path.Match)
+	// the object's name must satisfy.
+	NameGlob string `json:"nameGlob,omitempty"`
+
+	// Version is the release this migration was introduced in, recorded
+	// for operators reading the ConfigMap, not consulted by the code.
+	Version string `json:"version"`
+}
+
+// defaultLegacyResources is the embedded manifest used when no
+// legacyResourcesConfigMapName ConfigMap is present. It covers the
+// daemonsets named and labeled by the pre-LocalVolumeSet releases.
+//
+// These match by NameGlob alone. The pre-LocalVolumeSet code set the "app"
+// label to the DaemonSet's own Name (see managedDaemonSetPredicate), so it
+// carries the same per-CR suffix NameGlob already matches - an exact-equality
+// LabelSelector alongside it would never match a real object and would make
+// the migration silently skip everything it's meant to delete.
+var defaultLegacyResources = []LegacyResource{
+	{ID: "legacy-lv-diskmaker-daemonsets", Kind: "DaemonSet", NameGlob: "local-volume-diskmaker-*", Version: "4.5"},
+	{ID: "legacy-lv-provisioner-daemonsets", Kind: "DaemonSet", NameGlob: "local-volume-provisioner-*", Version: "4.5"},
+	{ID: "legacy-lvset-provisioner-daemonset", Kind: "DaemonSet", NameGlob: "localvolumeset-local-provisioner", Version: "4.6"},
+}
+
+func (lr LegacyResource) matches(obj metav1.Object) (bool, error) {
+	if lr.NameGlob != "" {
+		ok, err := path.Match(lr.NameGlob, obj.GetName())
+		if err != nil {
+			return false, fmt.Errorf("legacy resource %q has an invalid nameGlob: %w", lr.ID, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	if lr.LabelSelector != "" {
+		selector, err := labels.Parse(lr.LabelSelector)
+		if err != nil {
+			return false, fmt.Errorf("legacy resource %q has an invalid labelSelector: %w", lr.ID, err)
+		}
+		if !selector.Matches(labels.Set(obj.GetLabels())) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// runMigrations runs every LegacyResource migration that hasn't already
+// completed in namespace.
+func (r *DaemonReconciler) runMigrations(namespace string) error {
+	resources, err := r.loadLegacyResources(namespace)
+	if err != nil {
+		return err
+	}
+
+	completed, err := r.completedMigrations(namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, lr := range resources {
+		if completed[lr.ID] {
+			continue
+		}
+		if err := r.runMigration(namespace, lr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLegacyResources reads the declarative migration list from the
+// legacyResourcesConfigMapName ConfigMap, falling back to
+// defaultLegacyResources when it doesn't exist.
+func (r *DaemonReconciler) loadLegacyResources(namespace string) ([]LegacyResource, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: legacyResourcesConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		return defaultLegacyResources, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	resources := make([]LegacyResource, 0, len(cm.Data))
+	for id, raw := range cm.Data {
+		var lr LegacyResource
+		if err := yaml.Unmarshal([]byte(raw), &lr); err != nil {
+			return nil, fmt.Errorf("legacy resources configmap entry %q: %w", id, err)
+		}
+		lr.ID = id
+		resources = append(resources, lr)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].ID < resources[j].ID })
+	return resources, nil
+}
+
+// completedMigrations returns the set of migration IDs already recorded
+// as done in the migrationsConfigMapName ConfigMap.
+func (r *DaemonReconciler) completedMigrations(namespace string) (map[string]bool, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: migrationsConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	completed := make(map[string]bool, len(cm.Data))
+	for id := range cm.Data {
+		completed[id] = true
+	}
+	return completed, nil
+}
+
+// recordMigrationComplete marks migrationID as done in the
+// migrationsConfigMapName ConfigMap, creating it if this is the first
+// migration to run in namespace.
+func (r *DaemonReconciler) recordMigrationComplete(namespace, migrationID string) error {
+	cm := &corev1.ConfigMap{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: migrationsConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: migrationsConfigMapName, Namespace: namespace},
+			Data:       map[string]string{},
+		}
+		cm.Data[migrationID] = time.Now().UTC().Format(time.RFC3339)
+		return r.client.Create(context.TODO(), cm)
+	} else if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[migrationID] = time.Now().UTC().Format(time.RFC3339)
+	return r.client.Update(context.TODO(), cm)
+}
+
+// runMigration deletes every object matching lr and waits for its pods to
+// terminate before recording the migration as complete, emitting Events
+// on start, success and failure so the history survives beyond the
+// operator's logs.
+func (r *DaemonReconciler) runMigration(namespace string, lr LegacyResource) error {
+	subject := r.migrationEventSubject(namespace)
+	r.recorder.Eventf(subject, corev1.EventTypeNormal, "MigrationStarted", "starting legacy resource migration %q", lr.ID)
+
+	deletedAppLabels, err := r.deleteMatchingDaemonSets(namespace, lr)
+	if err != nil {
+		r.recorder.Eventf(subject, corev1.EventTypeWarning, "MigrationFailed", "migration %q failed to delete matching resources: %v", lr.ID, err)
+		return err
+	}
+
+	if err := r.waitForPodsGone(namespace, deletedAppLabels); err != nil {
+		r.recorder.Eventf(subject, corev1.EventTypeWarning, "MigrationFailed", "migration %q timed out waiting for pods to terminate: %v", lr.ID, err)
+		return err
+	}
+
+	if err := r.recordMigrationComplete(namespace, lr.ID); err != nil {
+		r.recorder.Eventf(subject, corev1.EventTypeWarning, "MigrationFailed", "migration %q could not be recorded as complete: %v", lr.ID, err)
+		return err
+	}
+
+	r.recorder.Eventf(subject, corev1.EventTypeNormal, "MigrationSucceeded", "completed legacy resource migration %q", lr.ID)
+	return nil
+}
+
+// migrationEventSubject returns the object migration Events are recorded
+// against. The migrations ConfigMap is the one object this controller
+// always owns in namespace, so it doubles as the event subject even
+// before it exists.
+func (r *DaemonReconciler) migrationEventSubject(namespace string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: migrationsConfigMapName, Namespace: namespace},
+	}
+}
+
+// deleteMatchingDaemonSets deletes every DaemonSet in namespace matching
+// lr and returns the "app" label value of each one deleted, so the
+// caller can wait for their pods to terminate.
+func (r *DaemonReconciler) deleteMatchingDaemonSets(namespace string, lr LegacyResource) ([]string, error) {
+	if lr.Kind != "DaemonSet" {
+		return nil, fmt.Errorf("legacy resource %q: unsupported kind %q", lr.ID, lr.Kind)
+	}
+
+	dsList := &appsv1.DaemonSetList{}
+	if err := r.client.List(context.TODO(), dsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	deletedAppLabels := make([]string, 0)
+	for i := range dsList.Items {
+		ds := &dsList.Items[i]
+		matched, err := lr.matches(ds)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+
+		if appLabel, ok := ds.Labels[appLabelKey]; ok {
+			deletedAppLabels = append(deletedAppLabels, appLabel)
+		} else {
+			deletedAppLabels = append(deletedAppLabels, ds.Name)
+		}
+
+		r.reqLogger.Info("deleting legacy resource", "migration.ID", lr.ID, "daemonset.Name", ds.Name)
+		if err := r.client.Delete(context.TODO(), ds); err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
+			return nil, err
+		}
+	}
+	return deletedAppLabels, nil
+}
+
+// waitForPodsGone blocks, with the same backoff the pre-migration-framework
+// cleanup used, until no pod carries an "app" label in appLabels.
+func (r *DaemonReconciler) waitForPodsGone(namespace string, appLabels []string) error {
+	if len(appLabels) == 0 {
+		return nil
+	}
+
+	return wait.ExponentialBackoff(wait.Backoff{
+		Cap:      time.Minute * 2,
+		Duration: time.Second,
+		Factor:   1.7,
+		Jitter:   1,
+		Steps:    20,
+	}, func() (bool, error) {
+		requirement, err := labels.NewRequirement(appLabelKey, selection.In, appLabels)
+		if err != nil {
+			return false, err
+		}
+		selector := labels.NewSelector().Add(*requirement)
+
+		podList := &corev1.PodList{}
+		err = r.client.List(context.TODO(), podList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector})
+		if err != nil {
+			return false, err
+		}
+		r.reqLogger.Info("waiting for legacy pods to terminate", "numberFound", len(podList.Items))
+		return len(podList.Items) == 0, nil
+	})
+}
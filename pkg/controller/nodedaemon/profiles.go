@@ -0,0 +1,123 @@
+package nodedaemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const hostnameLabelKey = "kubernetes.io/hostname"
+
+// nodeProfileGroup is the set of nodes that share one effective
+// provisioner config: either the base LocalVolume/LocalVolumeSet spec, or
+// a LocalVolumeNodeProfile layered on top of it.
+type nodeProfileGroup struct {
+	// name is empty for the base (unprofiled) group, so its ConfigMap and
+	// DaemonSets keep their original, un-suffixed names. Clusters with no
+	// LocalVolumeNodeProfile objects always resolve to a single group
+	// with an empty name.
+	name string
+
+	// nodeSelector scopes the group's DaemonSets to exactly the nodes
+	// assigned to it, by hostname. When the namespace has no
+	// LocalVolumeNodeProfile objects, the single base group keeps the
+	// aggregated base selector instead.
+	nodeSelector *corev1.NodeSelector
+
+	// override is nil for the base group.
+	override *localv1alpha1.LocalVolumeNodeProfileSpec
+}
+
+// resolveNodeProfiles groups every node matched by baseSelector under the
+// LocalVolumeNodeProfile (if any) that also matches it, so callers can
+// render one ConfigMap/DaemonSet pair per group instead of one uniform
+// pair for the whole cluster.
+func (r *DaemonReconciler) resolveNodeProfiles(namespace string, baseSelector *corev1.NodeSelector) ([]nodeProfileGroup, error) {
+	profileList := &localv1alpha1.LocalVolumeNodeProfileList{}
+	if err := r.client.List(context.TODO(), profileList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	if len(profileList.Items) == 0 {
+		return []nodeProfileGroup{{nodeSelector: baseSelector}}, nil
+	}
+
+	profiles := profileList.Items
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	nodes, err := r.matchingNodes(baseSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	nodesByProfile := map[string][]string{}
+	for _, node := range nodes {
+		profileName := ""
+		for _, profile := range profiles {
+			if profile.Spec.NodeSelector != nil && nodeMatchesSelector(node, *profile.Spec.NodeSelector) {
+				profileName = profile.Name
+				break
+			}
+		}
+		nodesByProfile[profileName] = append(nodesByProfile[profileName], node.Name)
+	}
+
+	groups := make([]nodeProfileGroup, 0, len(nodesByProfile))
+	for _, profile := range profiles {
+		names, ok := nodesByProfile[profile.Name]
+		if !ok {
+			continue
+		}
+		p := profile
+		groups = append(groups, nodeProfileGroup{
+			name:         profile.Name,
+			nodeSelector: hostnameSelector(names),
+			override:     &p.Spec,
+		})
+	}
+	if names, ok := nodesByProfile[""]; ok {
+		groups = append(groups, nodeProfileGroup{nodeSelector: hostnameSelector(names)})
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	return groups, nil
+}
+
+func hostnameSelector(nodeNames []string) *corev1.NodeSelector {
+	return &corev1.NodeSelector{
+		NodeSelectorTerms: []corev1.NodeSelectorTerm{
+			{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: hostnameLabelKey, Operator: corev1.NodeSelectorOpIn, Values: nodeNames},
+				},
+			},
+		},
+	}
+}
+
+// configMapName and daemonSetName suffix the base resource names with the
+// profile, leaving the base (unprofiled) group's resources named exactly
+// as they were before profiles existed.
+func (g nodeProfileGroup) configMapName() string {
+	if g.name == "" {
+		return provisionerConfigMapName
+	}
+	return fmt.Sprintf("%s-%s", provisionerConfigMapName, g.name)
+}
+
+func (g nodeProfileGroup) diskMakerName() string {
+	if g.name == "" {
+		return DiskMakerName
+	}
+	return fmt.Sprintf("%s-%s", DiskMakerName, g.name)
+}
+
+func (g nodeProfileGroup) provisionerName() string {
+	if g.name == "" {
+		return ProvisionerName
+	}
+	return fmt.Sprintf("%s-%s", ProvisionerName, g.name)
+}
@@ -1,32 +1,17 @@
 package nodedaemon
 
 import (
-	"context"
-	"fmt"
-	"strings"
-	"time"
-
 	"github.com/go-logr/logr"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/selection"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
-	oldProvisionerName     = "localvolumeset-local-provisioner"
-	oldLVDiskMakerPrefix   = "local-volume-diskmaker-"
-	oldLVProvisionerPrefix = "local-volume-provisioner-"
-	appLabelKey            = "app"
+	appLabelKey = "app"
 	// ProvisionerName is the name of the local-static-provisioner daemonset
 	ProvisionerName = "local-provisioner"
 	// DiskMakerName is the name of the diskmaker-manager daemonset
@@ -44,10 +29,10 @@ var _ reconcile.Reconciler = &DaemonReconciler{}
 type DaemonReconciler struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client                   client.Client
-	scheme                   *runtime.Scheme
-	reqLogger                logr.Logger
-	deletedStaticProvisioner bool
+	client    client.Client
+	scheme    *runtime.Scheme
+	reqLogger logr.Logger
+	recorder  record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a LocalVolumeSet object and makes changes based on the state read
@@ -58,8 +43,8 @@ type DaemonReconciler struct {
 func (r *DaemonReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	r.reqLogger = logf.Log.WithName(controllerName).WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 
-	// do a one-time delete of the old static-provisioner daemonset
-	err := r.cleanupOldDaemonsets(request.Namespace)
+	// migrate away any resources left over from older releases
+	err := r.runMigrations(request.Namespace)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -72,102 +57,87 @@ func (r *DaemonReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 		return reconcile.Result{}, nil
 	}
 
-	configMap, opResult, err := r.reconcileProvisionerConfigMap(request, lvSets.Items, lvs.Items, ownerRefs)
-	if err != nil {
-		return reconcile.Result{}, err
-	} else if opResult == controllerutil.OperationResultUpdated || opResult == controllerutil.OperationResultCreated {
-		r.reqLogger.Info("provisioner configmap changed")
+	cleanupPending := false
+	for i := range lvSets.Items {
+		lvSet := &lvSets.Items[i]
+		if lvSet.DeletionTimestamp != nil {
+			done, err := r.handleLocalVolumeSetDeletion(lvSet)
+			if err != nil {
+				r.reqLogger.Error(err, "failed to clean up LocalVolumeSet", "name", lvSet.Name)
+				return reconcile.Result{}, err
+			}
+			if !done {
+				cleanupPending = true
+			}
+		} else if err := r.ensureCleanupFinalizer(lvSet); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	for i := range lvs.Items {
+		lv := &lvs.Items[i]
+		if lv.DeletionTimestamp != nil {
+			done, err := r.handleLocalVolumeDeletion(lv)
+			if err != nil {
+				r.reqLogger.Error(err, "failed to clean up LocalVolume", "name", lv.Name)
+				return reconcile.Result{}, err
+			}
+			if !done {
+				cleanupPending = true
+			}
+		} else if err := r.ensureCleanupFinalizer(lv); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	if cleanupPending {
+		return reconcile.Result{RequeueAfter: cleanupRequeueAfter}, nil
 	}
 
-	configMapDataHash := dataHash(configMap.Data)
-
-	diskMakerDSMutateFn := getDiskMakerDSMutateFn(request, tolerations, ownerRefs, nodeSelector, configMapDataHash)
-	ds, opResult, err := CreateOrUpdateDaemonset(r.client, diskMakerDSMutateFn)
+	profileGroups, err := r.resolveNodeProfiles(request.Namespace, nodeSelector)
 	if err != nil {
+		r.reqLogger.Error(err, "failed to resolve LocalVolumeNodeProfiles")
 		return reconcile.Result{}, err
-	} else if opResult == controllerutil.OperationResultUpdated || opResult == controllerutil.OperationResultCreated {
-		r.reqLogger.Info("daemonset changed", "daemonset.Name", ds.GetName(), "op.Result", opResult)
 	}
 
-	return reconcile.Result{}, err
-}
+	daemonSetNames := make([]string, 0, len(profileGroups)*2)
+	for _, group := range profileGroups {
+		desiredConfigMap, err := buildProvisionerConfigMap(request, lvSets.Items, lvs.Items, ownerRefs, group.configMapName(), group.override)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		configMap, err := r.applyConfigMap(desiredConfigMap)
+		if err != nil {
+			r.reqLogger.Error(err, "failed to apply provisioner configmap", "configmap.Name", group.configMapName())
+			return reconcile.Result{}, err
+		}
 
-// do a one-time delete of the old static-provisioner daemonset
-func (r *DaemonReconciler) cleanupOldDaemonsets(namespace string) error {
-	if r.deletedStaticProvisioner {
-		return nil
-	}
+		configMapDataHash := dataHash(configMap.Data)
 
-	// search for old localvolume daemons
-	dsList := &appsv1.DaemonSetList{}
-	err := r.client.List(context.TODO(), dsList, client.InNamespace(namespace))
-	if err != nil {
-		r.reqLogger.Error(err, "could not list daemonsets")
-		return err
-	}
-	appNameList := make([]string, 0)
-	for _, ds := range dsList.Items {
-		appLabel, found := ds.ObjectMeta.Labels[appLabelKey]
-		if !found {
-			continue
-		} else if strings.HasPrefix(appLabel, oldLVDiskMakerPrefix) || strings.HasPrefix(appLabel, oldLVProvisionerPrefix) {
-			// remember name to watch for pods to delete
-			appNameList = append(appNameList, appLabel)
-			// delete daemonset
-			err = r.client.Delete(context.TODO(), &ds)
-			if err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
-				r.reqLogger.Error(err, "could not delete daemonset: %q", ds.Name)
-				return err
-			}
+		diskMakerDS, err := r.applyDaemonSet(buildDiskMakerDaemonSet(request, tolerations, ownerRefs, group.nodeSelector, configMapDataHash, group.diskMakerName(), group.configMapName()))
+		if err != nil {
+			r.reqLogger.Error(err, "failed to apply diskmaker daemonset")
+			return reconcile.Result{}, err
 		}
-	}
+		r.reqLogger.Info("applied daemonset", "daemonset.Name", diskMakerDS.GetName())
 
-	// search for old localvolumeset daemons
-	provisioner := &appsv1.DaemonSet{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Name: oldProvisionerName, Namespace: namespace}, provisioner)
-	if err == nil { // provisioner daemonset found
-		r.reqLogger.Info(fmt.Sprintf("old daemonset %q found, cleaning up", oldProvisionerName))
-		err = r.client.Delete(context.TODO(), provisioner)
-		if err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
-			r.reqLogger.Error(err, fmt.Sprintf("could not delete daemonset %q", oldProvisionerName))
-			return err
+		provisionerDS, err := r.applyDaemonSet(buildProvisionerDaemonSet(request, tolerations, ownerRefs, group.nodeSelector, configMapDataHash, group.provisionerName(), group.configMapName()))
+		if err != nil {
+			r.reqLogger.Error(err, "failed to apply provisioner daemonset")
+			return reconcile.Result{}, err
 		}
-	} else if !(errors.IsNotFound(err) || errors.IsGone(err)) { // unknown error
-		r.reqLogger.Error(err, fmt.Sprintf("could not fetch daemonset %q to clean it up", oldProvisionerName))
-		return err
+		r.reqLogger.Info("applied daemonset", "daemonset.Name", provisionerDS.GetName())
+
+		daemonSetNames = append(daemonSetNames, diskMakerDS.GetName(), provisionerDS.GetName())
 	}
 
-	// wait for pods to die
-	err = wait.ExponentialBackoff(wait.Backoff{
-		Cap:      time.Minute * 2,
-		Duration: time.Second,
-		Factor:   1.7,
-		Jitter:   1,
-		Steps:    20,
-	}, func() (done bool, err error) {
-		podList := &corev1.PodList{}
-		allGone := false
-		// search for any pods with label 'app' in appNameList
-		appNameList = append(appNameList, oldProvisionerName)
-		requirement, err := labels.NewRequirement(appLabelKey, selection.In, appNameList)
-		if err != nil {
-			r.reqLogger.Error(err, "failed to compose labelselector requirement %q in (%v)", appLabelKey, appNameList)
-			return false, err
-		}
-		selector := labels.NewSelector().Add(*requirement)
-		err = r.client.List(context.TODO(), podList, client.MatchingLabelsSelector{Selector: selector})
-		if err != nil && !errors.IsNotFound(err) {
-			return false, err
-		} else if len(podList.Items) == 0 {
-			allGone = true
-		}
-		r.reqLogger.Info(fmt.Sprintf("waiting for 0 pods with label app : %q", oldProvisionerName), "numberFound", len(podList.Items))
-		return allGone, nil
-	})
+	rollup, err := r.rollUpDaemonSetStatus(request.Namespace, daemonSetNames)
 	if err != nil {
-		r.reqLogger.Error(err, "could not determine that old provisioner pods were deleted")
-		return err
+		r.reqLogger.Error(err, "failed to roll up daemonset status")
+		return reconcile.Result{}, err
+	}
+	if err := r.patchOwnerConditions(lvSets.Items, lvs.Items, rollup.conditions()); err != nil {
+		r.reqLogger.Error(err, "failed to patch daemonset conditions onto owners")
+		return reconcile.Result{}, err
 	}
-	r.deletedStaticProvisioner = true
-	return nil
+
+	return reconcile.Result{}, nil
 }
@@ -0,0 +1,153 @@
+package nodedaemon
+
+import (
+	"context"
+	"fmt"
+
+	localv1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1"
+	localv1alpha1 "github.com/openshift/local-storage-operator/pkg/apis/local/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	conditionDaemonSetsAvailable   = "DaemonSetsAvailable"
+	conditionDaemonSetsProgressing = "DaemonSetsProgressing"
+	conditionDaemonSetsDegraded    = "DaemonSetsDegraded"
+)
+
+// daemonSetRollupStatus summarizes the combined status of the
+// diskmaker-manager and local-provisioner DaemonSets in a namespace.
+type daemonSetRollupStatus struct {
+	desired  int32
+	ready    int32
+	updated  int32
+	upToDate bool
+}
+
+// rollUpDaemonSetStatus reads back every managed DaemonSet named in
+// daemonSetNames (one diskmaker/provisioner pair per node profile group)
+// and folds their Status fields into a single summary used to derive
+// conditions.
+func (r *DaemonReconciler) rollUpDaemonSetStatus(namespace string, daemonSetNames []string) (daemonSetRollupStatus, error) {
+	rollup := daemonSetRollupStatus{upToDate: true}
+	for _, name := range daemonSetNames {
+		ds := &appsv1.DaemonSet{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, ds)
+		if errors.IsNotFound(err) {
+			rollup.upToDate = false
+			continue
+		} else if err != nil {
+			return daemonSetRollupStatus{}, err
+		}
+		rollup.desired += ds.Status.DesiredNumberScheduled
+		rollup.ready += ds.Status.NumberReady
+		rollup.updated += ds.Status.UpdatedNumberScheduled
+		if ds.Status.ObservedGeneration < ds.Generation {
+			rollup.upToDate = false
+		}
+	}
+	return rollup, nil
+}
+
+// conditions translates the rollup into the DaemonSetsAvailable,
+// DaemonSetsProgressing and DaemonSetsDegraded conditions surfaced on the
+// owning LocalVolume/LocalVolumeSet CRs.
+func (s daemonSetRollupStatus) conditions() []metav1.Condition {
+	now := metav1.Now()
+
+	available := metav1.ConditionTrue
+	availableReason := "DaemonSetsReady"
+	if s.desired == 0 || s.ready < s.desired {
+		available = metav1.ConditionFalse
+		availableReason = "DaemonSetsNotReady"
+	}
+
+	progressing := metav1.ConditionFalse
+	progressingReason := "DaemonSetsUpToDate"
+	if !s.upToDate || s.updated < s.desired {
+		progressing = metav1.ConditionTrue
+		progressingReason = "DaemonSetsRollingOut"
+	}
+
+	degraded := metav1.ConditionFalse
+	degradedReason := "DaemonSetsHealthy"
+	if s.desired > 0 && s.ready == 0 {
+		degraded = metav1.ConditionTrue
+		degradedReason = "DaemonSetsUnavailable"
+	}
+
+	return []metav1.Condition{
+		{
+			Type:               conditionDaemonSetsAvailable,
+			Status:             available,
+			Reason:             availableReason,
+			Message:            fmt.Sprintf("%d/%d daemonset pods ready", s.ready, s.desired),
+			LastTransitionTime: now,
+		},
+		{
+			Type:               conditionDaemonSetsProgressing,
+			Status:             progressing,
+			Reason:             progressingReason,
+			Message:            fmt.Sprintf("%d/%d daemonset pods updated", s.updated, s.desired),
+			LastTransitionTime: now,
+		},
+		{
+			Type:               conditionDaemonSetsDegraded,
+			Status:             degraded,
+			Reason:             degradedReason,
+			LastTransitionTime: now,
+		},
+	}
+}
+
+// patchOwnerConditions status-patches the rolled-up DaemonSet conditions
+// onto every LocalVolumeSet/LocalVolume in the namespace. It is issued as
+// its own Status().Patch, separate from the server-side-apply path used
+// for the DaemonSets and ConfigMap, so the two never race each other.
+func (r *DaemonReconciler) patchOwnerConditions(lvSets []localv1alpha1.LocalVolumeSet, lvs []localv1.LocalVolume, conditions []metav1.Condition) error {
+	for i := range lvSets {
+		lvSet := &lvSets[i]
+		patch := client.MergeFrom(lvSet.DeepCopy())
+		lvSet.Status.Conditions = mergeConditions(lvSet.Status.Conditions, conditions)
+		if err := r.client.Status().Patch(context.TODO(), lvSet, patch); err != nil {
+			return err
+		}
+	}
+	for i := range lvs {
+		lv := &lvs[i]
+		patch := client.MergeFrom(lv.DeepCopy())
+		lv.Status.Conditions = mergeConditions(lv.Status.Conditions, conditions)
+		if err := r.client.Status().Patch(context.TODO(), lv, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeConditions replaces each named condition in existing with its
+// updated value, preserving LastTransitionTime when the status hasn't
+// changed, and appends any condition type not already present.
+func mergeConditions(existing []metav1.Condition, updates []metav1.Condition) []metav1.Condition {
+	for _, update := range updates {
+		found := false
+		for i := range existing {
+			if existing[i].Type != update.Type {
+				continue
+			}
+			if existing[i].Status == update.Status {
+				update.LastTransitionTime = existing[i].LastTransitionTime
+			}
+			existing[i] = update
+			found = true
+			break
+		}
+		if !found {
+			existing = append(existing, update)
+		}
+	}
+	return existing
+}